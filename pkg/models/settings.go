@@ -8,7 +8,44 @@ import (
 )
 
 type PluginSettings struct {
-	Address    string                `json:"address"`
+	Address string `json:"address"`
+	// MaxRetries is how many times a failed aggregator request is retried,
+	// not counting the initial attempt. nil means the operator hasn't set
+	// it, which is not the same as an explicit 0 ("never retry") -- use
+	// MaxRetriesOrDefault rather than reading this field directly.
+	MaxRetries *int `json:"maxRetries,omitempty"`
+
+	TLSAuthWithCACert bool   `json:"tlsAuthWithCACert,omitempty"`
+	TLSSkipVerify     bool   `json:"tlsSkipVerify,omitempty"`
+	BasicAuthUser     string `json:"basicAuthUser,omitempty"`
+
+	Secrets *SecretPluginSettings `json:"-"`
+}
+
+// defaultMaxRetries is the retry count used when MaxRetries is unset.
+const defaultMaxRetries = 2
+
+// MaxRetriesOrDefault returns the configured retry count, or
+// defaultMaxRetries if the operator hasn't set one. A negative value is
+// treated the same as 0 ("never retry").
+func (s PluginSettings) MaxRetriesOrDefault() int {
+	if s.MaxRetries == nil {
+		return defaultMaxRetries
+	}
+	if *s.MaxRetries < 0 {
+		return 0
+	}
+	return *s.MaxRetries
+}
+
+// SecretPluginSettings holds the parts of the datasource config that come
+// from Grafana's encrypted secure JSON data rather than plain JSONData.
+type SecretPluginSettings struct {
+	BasicAuthPassword string
+	BearerToken       string
+	TLSCACert         string
+	TLSClientCert     string
+	TLSClientKey      string
 }
 
 func LoadPluginSettings(source backend.DataSourceInstanceSettings) (*PluginSettings, error) {
@@ -18,5 +55,17 @@ func LoadPluginSettings(source backend.DataSourceInstanceSettings) (*PluginSetti
 		return nil, fmt.Errorf("could not unmarshal PluginSettings json: %w", err)
 	}
 
+	settings.Secrets = loadSecretPluginSettings(source.DecryptedSecureJSONData)
+
 	return &settings, nil
-}
\ No newline at end of file
+}
+
+func loadSecretPluginSettings(source map[string]string) *SecretPluginSettings {
+	return &SecretPluginSettings{
+		BasicAuthPassword: source["basicAuthPassword"],
+		BearerToken:       source["bearerToken"],
+		TLSCACert:         source["tlsCACert"],
+		TLSClientCert:     source["tlsClientCert"],
+		TLSClientKey:      source["tlsClientKey"],
+	}
+}
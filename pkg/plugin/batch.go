@@ -0,0 +1,179 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/errorsource"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// BatchQuery is one entry of a batched /timeseries/{device}/batch request.
+type BatchQuery struct {
+	RefID          string `json:"refId"`
+	XPathQuery     string `json:"xpathQuery"`
+	Type           string `json:"type"`
+	ContainsString string `json:"containsString,omitempty"`
+	LabelXpath     string `json:"labelXpath,omitempty"`
+}
+
+// BatchSeriesResult is the outcome of a single query within a batch. Err is
+// set when the aggregator reported a failure for this refId specifically,
+// or when coercing that refId's data failed; the remaining refIds in the
+// same batch may still have succeeded. Use isDownstreamBatchError(Err) to
+// tell which it was.
+type BatchSeriesResult struct {
+	Data []map[string]interface{}
+	Err  error
+}
+
+// batchError wraps an error returned from GetDeviceDataBatch with whether
+// it should be attributed to the aggregator rather than the plugin, so
+// QueryData can pick the right errorsource classification without
+// re-deriving it from the error text.
+type batchError struct {
+	err        error
+	downstream bool
+}
+
+func (e *batchError) Error() string { return e.err.Error() }
+func (e *batchError) Unwrap() error { return e.err }
+
+// pluginBatchError marks err as caused by the plugin itself (bad config,
+// malformed request, failed xpath/type coercion).
+func pluginBatchError(err error) error {
+	return &batchError{err: err}
+}
+
+// downstreamBatchError marks err as caused by the aggregator (transport
+// failure, non-2xx response, a per-refId failure it reported).
+func downstreamBatchError(err error) error {
+	return &batchError{err: err, downstream: true}
+}
+
+// isDownstreamBatchError reports whether err (or a wrapped cause) was
+// tagged as an aggregator-side failure by GetDeviceDataBatch.
+func isDownstreamBatchError(err error) bool {
+	var be *batchError
+	return errors.As(err, &be) && be.downstream
+}
+
+// classifyBatchError picks the metrics label and errorsource wrapper for an
+// error coming out of GetDeviceDataBatch.
+func classifyBatchError(err error) (label string, wrap func(error, bool) error) {
+	if isDownstreamBatchError(err) {
+		return "downstream_error", errorsource.DownstreamError
+	}
+	return "plugin_error", errorsource.PluginError
+}
+
+// batchSnapshotResult mirrors one element of the aggregator's batch response:
+// the raw snapshots for a refId, or an error if that refId's query failed.
+type batchSnapshotResult struct {
+	RefID string                   `json:"refId"`
+	Data  []map[string]interface{} `json:"data"`
+	Error string                   `json:"error,omitempty"`
+}
+
+// GetDeviceDataBatch fetches and coerces the results for every query against
+// a single device in one HTTP round trip, returning per-refId results so
+// callers can surface partial failures without discarding the rest of the
+// batch.
+func (d *DeviceDataFetcher) GetDeviceDataBatch(ctx context.Context, deviceID string, queries []BatchQuery) (map[string]BatchSeriesResult, error) {
+	refIDs := make([]string, 0, len(queries))
+	for _, q := range queries {
+		refIDs = append(refIDs, q.RefID)
+	}
+	ctx, span := startSpan(ctx, "deviceDataFetcher.GetDeviceDataBatch",
+		attribute.String("device", deviceID),
+		attribute.StringSlice("ref_ids", refIDs),
+	)
+	defer span.End()
+
+	if d.Address == "" {
+		return nil, pluginBatchError(fmt.Errorf("datasource address is not configured"))
+	}
+
+	if !strings.HasPrefix(d.Address, "http://") && !strings.HasPrefix(d.Address, "https://") {
+		return nil, pluginBatchError(fmt.Errorf("datasource address must include http:// or https://"))
+	}
+
+	if deviceID == "" {
+		return nil, pluginBatchError(fmt.Errorf("device ID is required"))
+	}
+
+	if len(queries) == 0 {
+		return nil, pluginBatchError(fmt.Errorf("at least one query is required"))
+	}
+
+	batchURL := fmt.Sprintf("%s/timeseries/%s/batch", d.Address, deviceID)
+	bodyBytes, err := json.Marshal(queries)
+	if err != nil {
+		return nil, pluginBatchError(fmt.Errorf("failed to marshal batch request body: %w", err))
+	}
+
+	resp, err := doWithRetry(ctx, d.Client, d.MaxRetries, func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(ctx, "POST", batchURL, bytes.NewReader(bodyBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+
+		request.Header.Set("Accept", "*/*")
+		request.Header.Set("Accept-Encoding", "gzip, deflate, br")
+		request.Header.Set("Connection", "keep-alive")
+		request.Header.Set("Content-Type", "application/json")
+		return request, nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, downstreamBatchError(fmt.Errorf("failed to fetch batched device data: %w", err))
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, downstreamBatchError(fmt.Errorf("failed to read response: %w", err))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, downstreamBatchError(fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(responseBody)))
+	}
+
+	var batchResponse []batchSnapshotResult
+	if err := json.Unmarshal(responseBody, &batchResponse); err != nil {
+		return nil, downstreamBatchError(fmt.Errorf("failed to parse batch response JSON: %w", err))
+	}
+
+	queriesByRefID := make(map[string]BatchQuery, len(queries))
+	for _, q := range queries {
+		queriesByRefID[q.RefID] = q
+	}
+
+	results := make(map[string]BatchSeriesResult, len(batchResponse))
+	for _, entry := range batchResponse {
+		if entry.Error != "" {
+			results[entry.RefID] = BatchSeriesResult{Err: downstreamBatchError(fmt.Errorf("%s", entry.Error))}
+			continue
+		}
+
+		q, ok := queriesByRefID[entry.RefID]
+		if !ok {
+			continue
+		}
+
+		processed, err := processSnapshots(entry.Data, q.XPathQuery, q.Type, q.ContainsString, q.LabelXpath)
+		if err != nil {
+			results[entry.RefID] = BatchSeriesResult{Err: pluginBatchError(err)}
+			continue
+		}
+		results[entry.RefID] = BatchSeriesResult{Data: processed}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetDeviceDataBatch_ConfigErrorsAreClassifiedAsPlugin(t *testing.T) {
+	cases := []struct {
+		name    string
+		fetcher DeviceDataFetcher
+		device  string
+		queries []BatchQuery
+	}{
+		{"missing address", DeviceDataFetcher{}, "dev1", []BatchQuery{{RefID: "A", XPathQuery: "/x", Type: "string"}}},
+		{"missing scheme", DeviceDataFetcher{Address: "aggregator:8080"}, "dev1", []BatchQuery{{RefID: "A", XPathQuery: "/x", Type: "string"}}},
+		{"missing device", DeviceDataFetcher{Address: "http://aggregator"}, "", []BatchQuery{{RefID: "A", XPathQuery: "/x", Type: "string"}}},
+		{"no queries", DeviceDataFetcher{Address: "http://aggregator"}, "dev1", nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.fetcher.GetDeviceDataBatch(context.Background(), tc.device, tc.queries)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if isDownstreamBatchError(err) {
+				t.Fatalf("expected a plugin error, got a downstream error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetDeviceDataBatch_ErrorStatusIsClassifiedAsDownstream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	fetcher := DeviceDataFetcher{Address: server.URL, Client: server.Client()}
+	_, err := fetcher.GetDeviceDataBatch(context.Background(), "dev1", []BatchQuery{{RefID: "A", XPathQuery: "/x", Type: "string"}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !isDownstreamBatchError(err) {
+		t.Fatalf("expected a downstream error, got a plugin error: %v", err)
+	}
+}
+
+func TestGetDeviceDataBatch_AggregatorReportedFailureIsClassifiedAsDownstream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"refId":"A","error":"device unreachable"}]`))
+	}))
+	defer server.Close()
+
+	fetcher := DeviceDataFetcher{Address: server.URL, Client: server.Client()}
+	results, err := fetcher.GetDeviceDataBatch(context.Background(), "dev1", []BatchQuery{{RefID: "A", XPathQuery: "/x", Type: "string"}})
+	if err != nil {
+		t.Fatalf("unexpected top-level error: %v", err)
+	}
+
+	result, ok := results["A"]
+	if !ok || result.Err == nil {
+		t.Fatal("expected a per-refId error")
+	}
+	if !isDownstreamBatchError(result.Err) {
+		t.Fatalf("expected a downstream error, got a plugin error: %v", result.Err)
+	}
+}
+
+func TestGetDeviceDataBatch_CoercionFailureIsClassifiedAsPlugin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"refId":"A","data":[{"timestamp":"2024-01-01T00:00:00Z","xml":"<root><v>not-a-number</v></root>"}]}]`))
+	}))
+	defer server.Close()
+
+	fetcher := DeviceDataFetcher{Address: server.URL, Client: server.Client()}
+	results, err := fetcher.GetDeviceDataBatch(context.Background(), "dev1", []BatchQuery{{RefID: "A", XPathQuery: "//v", Type: "int"}})
+	if err != nil {
+		t.Fatalf("unexpected top-level error: %v", err)
+	}
+
+	result, ok := results["A"]
+	if !ok || result.Err == nil {
+		t.Fatal("expected a per-refId error")
+	}
+	if isDownstreamBatchError(result.Err) {
+		t.Fatalf("expected a plugin error, got a downstream error: %v", result.Err)
+	}
+}
@@ -1,21 +1,21 @@
 package plugin
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"regexp"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/errorsource"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/lsi/netconf-aggregator/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Make sure Datasource implements required interfaces. This is important to do
@@ -28,6 +28,7 @@ var (
 	_ backend.CheckHealthHandler    = (*Datasource)(nil)
 	_ instancemgmt.InstanceDisposer = (*Datasource)(nil)
 	_ backend.CallResourceHandler   = (*Datasource)(nil)
+	_ backend.StreamHandler         = (*Datasource)(nil)
 )
 
 // NewDatasource creates a new datasource instance.
@@ -37,9 +38,22 @@ func NewDatasource(_ context.Context, settings backend.DataSourceInstanceSetting
 		return nil, fmt.Errorf("error loading settings: %s", err.Error())
 	}
 
+	httpClient, err := newHTTPClient(*config, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error building HTTP client: %w", err)
+	}
+
+	streamClient, err := newHTTPClient(*config, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error building streaming HTTP client: %w", err)
+	}
+
 	return &Datasource{
-		settings: settings,
-		config:   *config,
+		settings:     settings,
+		config:       *config,
+		httpClient:   httpClient,
+		streamClient: streamClient,
+		liveQueries:  make(map[string]liveQuerySpec),
 	}, nil
 }
 
@@ -48,13 +62,32 @@ func NewDatasource(_ context.Context, settings backend.DataSourceInstanceSetting
 type Datasource struct {
 	settings backend.DataSourceInstanceSettings
 	config   models.PluginSettings
+
+	// httpClient and streamClient are built once from config's TLS/auth
+	// settings via the plugin SDK's httpclient provider and reused for
+	// every request to the aggregator, rather than allocating a fresh
+	// client per call. streamClient has no overall request timeout since
+	// it backs long-lived event stream connections.
+	httpClient   *http.Client
+	streamClient *http.Client
+
+	// liveQueries maps a stream channel path to the query it was created
+	// for, so RunStream can re-run the same XPath/type coercion used by
+	// QueryData against each incoming event.
+	liveQueriesMu sync.RWMutex
+	liveQueries   map[string]liveQuerySpec
 }
 
 // Dispose here tells plugin SDK that plugin wants to clean up resources when a new instance
 // created. As soon as datasource settings change detected by SDK old datasource instance will
 // be disposed and a new one will be created using NewSampleDatasource factory function.
 func (d *Datasource) Dispose() {
-	// Clean up datasource instance resources.
+	if d.httpClient != nil {
+		d.httpClient.CloseIdleConnections()
+	}
+	if d.streamClient != nil {
+		d.streamClient.CloseIdleConnections()
+	}
 }
 
 // QueryData handles multiple queries and returns multiple responses.
@@ -62,176 +95,253 @@ func (d *Datasource) Dispose() {
 // The QueryDataResponse contains a map of RefID to the response for each query, and each response
 // contains Frames ([]*Frame).
 func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	ctx, span := startSpan(ctx, "datasource.QueryData", attribute.Int("query_count", len(req.Queries)))
+	defer span.End()
+
 	// Create response struct
 	response := backend.NewQueryDataResponse()
-	// Loop over queries and execute them individually
+
+	// Parse every query up front and group the valid ones by device so each
+	// device is hit with a single batched HTTP call instead of one per panel.
+	queryModels := make(map[string]queryModel, len(req.Queries))
+	byDevice := make(map[string][]BatchQuery)
 	for _, q := range req.Queries {
 		var qm queryModel
-		err := json.Unmarshal(q.JSON, &qm)
-		if err != nil {
-			response.Responses[q.RefID] = backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("json unmarshal: %v", err.Error()))
-			continue
-		}
-
-		dataFetcher := DeviceDataFetcher{Address: d.config.Address}
-		deviceData, err := dataFetcher.GetDeviceData(qm.Device, qm.QueryText, qm.Type, qm.ContainsString)
-		if err != nil {
-			response.Responses[q.RefID] = backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("data fetch error: %v", err.Error()))
+		if err := json.Unmarshal(q.JSON, &qm); err != nil {
+			recordRequest("QueryData", "unknown", "plugin_error")
+			response.Responses[q.RefID] = errorsource.Response(errorsource.PluginError(fmt.Errorf("json unmarshal: %w", err), false))
 			continue
 		}
 
-		frame := data.NewFrame("response")
-		timestamps := []time.Time{}
-		var values interface{}
-
-		if qm.Type == "int" {
-			values = []int64{}
-		} else if qm.Type == "contains" {
-			values = []bool{}
-		} else {
-			response.Responses[q.RefID] = backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("unsupported query type: %s", qm.Type))
+		if qm.Live {
+			spec := liveQuerySpec{
+				Device:         qm.Device,
+				XPathQuery:     qm.QueryText,
+				Type:           qm.Type,
+				ContainsString: qm.ContainsString,
+				LabelXpath:     qm.LabelXpath,
+			}
+			channel := liveChannel(spec)
+			d.liveQueriesMu.Lock()
+			d.liveQueries[channel] = spec
+			d.liveQueriesMu.Unlock()
+
+			frame := data.NewFrame("response")
+			frame.Meta = &data.FrameMeta{Channel: channel}
+			recordRequest("QueryData", qm.Type, "ok")
+			response.Responses[q.RefID] = backend.DataResponse{Frames: []*data.Frame{frame}}
 			continue
 		}
 
-		for _, item := range deviceData {
-			timestamp, _ := time.Parse(time.RFC3339, item["timestamp"].(string))
-			timestamps = append(timestamps, timestamp)
+		queryModels[q.RefID] = qm
+		byDevice[qm.Device] = append(byDevice[qm.Device], BatchQuery{
+			RefID:          q.RefID,
+			XPathQuery:     qm.QueryText,
+			Type:           qm.Type,
+			ContainsString: qm.ContainsString,
+			LabelXpath:     qm.LabelXpath,
+		})
+	}
 
-			if qm.Type == "int" {
-				values = append(values.([]int64), int64(item["value"].(int)))
-			} else if qm.Type == "contains" {
-				values = append(values.([]bool), item["value"].(bool))
-			}
+	dataFetcher := DeviceDataFetcher{Address: d.config.Address, MaxRetries: d.config.MaxRetriesOrDefault(), Client: d.httpClient}
+	for device, batchQueries := range byDevice {
+		refIDs := make([]string, 0, len(batchQueries))
+		xpaths := make([]string, 0, len(batchQueries))
+		for _, bq := range batchQueries {
+			refIDs = append(refIDs, bq.RefID)
+			xpaths = append(xpaths, truncateXPath(bq.XPathQuery))
 		}
 
-		frame.Fields = append(frame.Fields,
-			data.NewField("time", nil, timestamps),
-			data.NewField("value", nil, values),
+		batchCtx, batchSpan := startSpan(ctx, "datasource.fetchDeviceBatch",
+			attribute.String("device", device),
+			attribute.StringSlice("ref_ids", refIDs),
+			attribute.StringSlice("xpaths", xpaths),
 		)
 
-		response.Responses[q.RefID] = backend.DataResponse{
-			Frames: []*data.Frame{frame},
+		start := time.Now()
+		results, err := dataFetcher.GetDeviceDataBatch(batchCtx, device, batchQueries)
+		duration := time.Since(start).Seconds()
+		if err != nil {
+			batchSpan.RecordError(err)
 		}
-	}
-	return response, nil
-}
+		batchSpan.End()
 
-type DeviceDataFetcher struct {
-	Address string
-}
+		if err != nil {
+			label, wrap := classifyBatchError(err)
+			for _, bq := range batchQueries {
+				recordRequest("QueryData", bq.Type, label)
+				requestDuration.WithLabelValues("QueryData", bq.Type).Observe(duration)
+				response.Responses[bq.RefID] = errorsource.Response(wrap(fmt.Errorf("data fetch error: %w", err), false))
+			}
+			continue
+		}
 
-func (d *DeviceDataFetcher) GetDeviceData(deviceID string, xpathQuery string, qtype string, qstring string) ([]map[string]interface{}, error) {
-	
-	if d.Address == "" {
-		return nil, fmt.Errorf("datasource address is not configured")
-	}
+		for _, bq := range batchQueries {
+			requestDuration.WithLabelValues("QueryData", bq.Type).Observe(duration)
 
-	if !strings.HasPrefix(d.Address, "http://") && !strings.HasPrefix(d.Address, "https://") {
-		return nil, fmt.Errorf("datasource address must include http:// or https://")
-	}
+			result, ok := results[bq.RefID]
+			if !ok {
+				recordRequest("QueryData", bq.Type, "plugin_error")
+				response.Responses[bq.RefID] = errorsource.Response(errorsource.PluginError(fmt.Errorf("batch response missing refId"), false))
+				continue
+			}
+			if result.Err != nil {
+				label, wrap := classifyBatchError(result.Err)
+				recordRequest("QueryData", bq.Type, label)
+				response.Responses[bq.RefID] = errorsource.Response(wrap(fmt.Errorf("data fetch error: %w", result.Err), false))
+				continue
+			}
 
-	if deviceID == "" {
-		return nil, fmt.Errorf("device ID is required")
-	}
+			frame, err := buildFrame(queryModels[bq.RefID].Type, result.Data)
+			if err != nil {
+				recordRequest("QueryData", bq.Type, "plugin_error")
+				response.Responses[bq.RefID] = errorsource.Response(errorsource.PluginError(err, false))
+				continue
+			}
 
-	if xpathQuery == "" {
-		return nil, fmt.Errorf("xpathQuery is required")
+			recordRequest("QueryData", bq.Type, "ok")
+			response.Responses[bq.RefID] = backend.DataResponse{
+				Frames: []*data.Frame{frame},
+			}
+		}
 	}
 
-	deviceDataURL := fmt.Sprintf("%s/timeseries/%s", d.Address, deviceID)
-	body := map[string]string{"xpathQuery": xpathQuery}
-	bodyBytes, err := json.Marshal(body)
+	return response, nil
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+// buildFrame turns the processed {"timestamp", "value", "label"} rows
+// returned for a query into a type-driven data.Frame.
+func buildFrame(qtype string, deviceData []map[string]interface{}) (*data.Frame, error) {
+	frame := data.NewFrame("response")
+	timestamps := []time.Time{}
+	var values interface{}
+	var labels []string
+
+	switch qtype {
+	case "int":
+		values = []int64{}
+	case "float":
+		values = []float64{}
+	case "string", "enum":
+		values = []string{}
+	case "contains":
+		values = []bool{}
+	case "multi":
+		values = []string{}
+		labels = []string{}
+	default:
+		return nil, fmt.Errorf("unsupported query type: %s", qtype)
 	}
 
-	reqBody := bytes.NewReader(bodyBytes)
-	request, err := http.NewRequest("POST", deviceDataURL, reqBody)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-	
-	request.Header.Set("Accept", "*/*")
-	request.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	request.Header.Set("Connection", "keep-alive")
-	request.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	for _, item := range deviceData {
+		rawTimestamp, ok := item["timestamp"].(string)
+		if !ok {
+			return nil, fmt.Errorf("row is missing a string timestamp")
+		}
+		timestamp, err := time.Parse(time.RFC3339, rawTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", rawTimestamp, err)
+		}
+		timestamps = append(timestamps, timestamp)
 
-	resp, err := client.Do(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch device data: %w", err)
+		switch qtype {
+		case "int":
+			v, ok := item["value"].(int64)
+			if !ok {
+				return nil, fmt.Errorf("row value is not an int")
+			}
+			values = append(values.([]int64), v)
+		case "float":
+			v, ok := item["value"].(float64)
+			if !ok {
+				return nil, fmt.Errorf("row value is not a float")
+			}
+			values = append(values.([]float64), v)
+		case "string", "enum":
+			v, ok := item["value"].(string)
+			if !ok {
+				return nil, fmt.Errorf("row value is not a string")
+			}
+			values = append(values.([]string), v)
+		case "contains":
+			v, ok := item["value"].(bool)
+			if !ok {
+				return nil, fmt.Errorf("row value is not a bool")
+			}
+			values = append(values.([]bool), v)
+		case "multi":
+			v, ok := item["value"].(string)
+			if !ok {
+				return nil, fmt.Errorf("row value is not a string")
+			}
+			label, ok := item["label"].(string)
+			if !ok {
+				return nil, fmt.Errorf("row label is not a string")
+			}
+			values = append(values.([]string), v)
+			labels = append(labels, label)
+		}
 	}
 
-	defer resp.Body.Close()
-	responseBody, err := io.ReadAll(resp.Body)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	frame.Fields = append(frame.Fields,
+		data.NewField("time", nil, timestamps),
+		data.NewField("value", nil, values),
+	)
+	if qtype == "multi" {
+		frame.Fields = append(frame.Fields, data.NewField("label", nil, labels))
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(responseBody))
-	}
+	return frame, nil
+}
 
-	// Parse the response to process based on query type
-	var responseData []map[string]interface{}
-	err = json.Unmarshal(responseBody, &responseData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse response JSON: %w", err)
-	}
+type DeviceDataFetcher struct {
+	Address    string
+	MaxRetries int
+	Client     *http.Client
+}
 
+// processSnapshots applies the XPath/type coercion to a list of raw device
+// snapshots ({"timestamp": ..., "xml": ...}), producing zero or more
+// {"timestamp": ..., "value": ...} rows per snapshot ("label" is added only
+// for "multi"). Snapshots without a string "xml" field, or whose xpath
+// matches nothing, produce no rows. It is
+// used by GetDeviceDataBatch and by RunStream to coerce batched and live
+// events identically.
+func processSnapshots(snapshots []map[string]interface{}, xpathQuery string, qtype string, qstring string, labelXpath string) ([]map[string]interface{}, error) {
 	var processedData []map[string]interface{}
-	for _, item := range responseData {
+	for _, item := range snapshots {
 		xmlData, ok := item["xml"].(string)
 		if !ok {
 			continue
 		}
 
-		// Process based on query type
-		switch qtype {
-		case "int":
-			// Extract the first integer from the XML
-			firstInteger := extractFirstInteger(xmlData)
-			processedData = append(processedData, map[string]interface{}{
-				"timestamp": item["timestamp"],
-				"value":     firstInteger,
-			})
-		case "contains":
-			// Check if the XML contains the query string and return true/false
-			contains := strings.Contains(xmlData, qstring)
-			processedData = append(processedData, map[string]interface{}{
+		values, err := evaluateXPath(xmlData, xpathQuery, labelXpath, qtype, qstring)
+		if err != nil {
+			return nil, fmt.Errorf("xpath evaluation failed: %w", err)
+		}
+
+		for _, v := range values {
+			row := map[string]interface{}{
 				"timestamp": item["timestamp"],
-				"value":     contains,
-			})
-		default:
-			return nil, fmt.Errorf("unsupported query type: %s", qtype)
+				"value":     v.Value,
+			}
+			if qtype == "multi" {
+				row["label"] = v.Label
+			}
+			processedData = append(processedData, row)
 		}
 	}
 
 	return processedData, nil
 }
 
-// Helper function to extract the first integer from a string
-func extractFirstInteger(input string) int {
-	re := regexp.MustCompile(`\d+`)
-	match := re.FindString(input)
-	if match == "" {
-		return 0 // Return 0 if no integer is found
-	}
-	intValue, _ := strconv.Atoi(match)
-	return intValue
-}
-
 type queryModel struct {
 	Type           string `json:"type"`
 	ContainsString string `json:"containsString"`
 	QueryText      string `json:"xpath"`
 	Device         string `json:"device"`
+	LabelXpath     string `json:"labelXpath,omitempty"`
+	Live           bool   `json:"live,omitempty"`
 }
 
 func (d *Datasource) query(_ context.Context, pCtx backend.PluginContext, query backend.DataQuery) backend.DataResponse {
@@ -266,7 +376,10 @@ func (d *Datasource) query(_ context.Context, pCtx backend.PluginContext, query
 // The main use case for these health checks is the test button on the
 // datasource configuration page which allows users to verify that
 // a datasource is working as expected.
-func (d *Datasource) CheckHealth(_ context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	_, span := startSpan(ctx, "datasource.CheckHealth")
+	defer span.End()
+
 	res := &backend.CheckHealthResult{}
 	config, err := models.LoadPluginSettings(*req.PluginContext.DataSourceInstanceSettings)
 
@@ -284,10 +397,46 @@ func (d *Datasource) CheckHealth(_ context.Context, req *backend.CheckHealthRequ
 		return res, nil
 	}
 
-	return &backend.CheckHealthResult{
-		Status:  backend.HealthStatusOk,
-		Message: "Data source is working",
-	}, nil
+	devicesURL := fmt.Sprintf("%s/devices", config.Address)
+	request, err := http.NewRequestWithContext(ctx, "GET", devicesURL, nil)
+	if err != nil {
+		res.Status = backend.HealthStatusError
+		res.Message = fmt.Sprintf("Failed to build health check request: %s", err.Error())
+		return res, nil
+	}
+
+	resp, err := d.httpClient.Do(request)
+	if err != nil {
+		span.RecordError(err)
+		res.Status = backend.HealthStatusError
+		res.Message = healthCheckErrorMessage(err)
+		return res, nil
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		res.Status = backend.HealthStatusError
+		res.Message = fmt.Sprintf("Authentication with the aggregator was rejected (status %d)", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		res.Status = backend.HealthStatusError
+		res.Message = fmt.Sprintf("Aggregator returned status %d", resp.StatusCode)
+	default:
+		res.Status = backend.HealthStatusOk
+		res.Message = "Data source is working"
+	}
+
+	return res, nil
+}
+
+// healthCheckErrorMessage turns a failed health check request into a message
+// that tells the user whether it was TLS or plain connectivity that failed.
+func healthCheckErrorMessage(err error) string {
+	msg := err.Error()
+	if strings.Contains(msg, "x509:") || strings.Contains(msg, "tls:") || strings.Contains(msg, "certificate") {
+		return fmt.Sprintf("TLS handshake with the aggregator failed: %s", msg)
+	}
+	return fmt.Sprintf("Failed to reach the aggregator: %s", msg)
 }
 
 // Device represents the device information structure as returned by your API
@@ -299,6 +448,9 @@ type Device struct {
 
 // CallResource implements the backend.CallResourceHandler interface
 func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	ctx, span := startSpan(ctx, "datasource.CallResource", attribute.String("path", req.Path))
+	defer span.End()
+
 	backend.Logger.Debug("CallResource invoked", "path", req.Path)
 	if req.Path == "devices" {
 		backend.Logger.Debug("Calling getDevices handler")
@@ -314,12 +466,10 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 
 // getDevices handles the /devices endpoint
 func (d *Datasource) getDevices(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
-	// Create a client to fetch data from your actual service
-	backend.Logger.Debug("Datasource address", "address", d.config.Address)
+	ctx, span := startSpan(ctx, "datasource.getDevices")
+	defer span.End()
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	backend.Logger.Debug("Datasource address", "address", d.config.Address)
 
 	if d.config.Address == "" {
 		return sender.Send(&backend.CallResourceResponse{
@@ -337,8 +487,11 @@ func (d *Datasource) getDevices(ctx context.Context, req *backend.CallResourceRe
 	devicesURL := fmt.Sprintf("%s/devices", d.config.Address)
 
 	// Make the request
-	resp, err := client.Get(devicesURL)
+	resp, err := doWithRetry(ctx, d.httpClient, d.config.MaxRetriesOrDefault(), func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", devicesURL, nil)
+	})
 	if err != nil {
+		span.RecordError(err)
 		return sender.Send(&backend.CallResourceResponse{
 			Status: http.StatusInternalServerError,
 			Body:   []byte(fmt.Sprintf(`{"error": "Failed to fetch devices: %s"}`, err.Error())),
@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/lsi/netconf-aggregator/pkg/models"
+)
+
+// newHTTPClient builds the *http.Client used for every aggregator request
+// from the datasource's TLS/auth settings, via the plugin SDK's provider so
+// Grafana's proxy, TLS and auth options are honored the same way every other
+// datasource honors them. timeout is the overall per-request timeout; pass 0
+// for the long-lived streaming client, which relies on context cancellation
+// instead.
+func newHTTPClient(config models.PluginSettings, timeout time.Duration) (*http.Client, error) {
+	opts := httpclient.Options{
+		Timeouts: &httpclient.TimeoutOptions{
+			Timeout: timeout,
+		},
+	}
+
+	secrets := config.Secrets
+	if secrets == nil {
+		secrets = &models.SecretPluginSettings{}
+	}
+
+	tlsOpts := &httpclient.TLSOptions{
+		ClientCertificate:  secrets.TLSClientCert,
+		ClientKey:          secrets.TLSClientKey,
+		InsecureSkipVerify: config.TLSSkipVerify,
+	}
+	if config.TLSAuthWithCACert {
+		tlsOpts.CACertificate = secrets.TLSCACert
+	}
+	opts.TLS = tlsOpts
+
+	if config.BasicAuthUser != "" {
+		opts.BasicAuth = &httpclient.BasicAuthOptions{
+			User:     config.BasicAuthUser,
+			Password: secrets.BasicAuthPassword,
+		}
+	}
+
+	if secrets.BearerToken != "" {
+		opts.Header = http.Header{
+			"Authorization": []string{"Bearer " + secrets.BearerToken},
+		}
+	}
+
+	client, err := httpclient.NewProvider().New(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP client: %w", err)
+	}
+
+	return client, nil
+}
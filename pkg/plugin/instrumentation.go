@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "netconf_aggregator",
+		Name:      "datasource_requests_total",
+		Help:      "Total requests handled by the netconf aggregator datasource, by handler, query type and outcome.",
+	}, []string{"handler", "query_type", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "netconf_aggregator",
+		Name:      "datasource_request_duration_seconds",
+		Help:      "Duration of requests handled by the netconf aggregator datasource.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler", "query_type"})
+)
+
+// maxSpanXPathLen bounds how much of an xpath is attached to a span so a
+// pathological query can't bloat trace payloads.
+const maxSpanXPathLen = 256
+
+// truncateXPath shortens xpath for use as a span attribute.
+func truncateXPath(xpath string) string {
+	if len(xpath) <= maxSpanXPathLen {
+		return xpath
+	}
+	return xpath[:maxSpanXPathLen] + "..."
+}
+
+// startSpan opens a child span under the plugin SDK's default tracer,
+// attaching attrs immediately so callers don't have to null-check the span.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, name)
+	if len(attrs) > 0 {
+		span.SetAttributes(attrs...)
+	}
+	return ctx, span
+}
+
+// recordRequest increments the request counter for a handler/query
+// type/outcome triple. status is one of "ok", "plugin_error" or
+// "downstream_error".
+func recordRequest(handler, queryType, status string) {
+	requestsTotal.WithLabelValues(handler, queryType, status).Inc()
+}
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// retryBackoff returns the delay before the given retry attempt (0-indexed):
+// 100ms, 400ms, 1600ms, ...
+func retryBackoff(attempt int) time.Duration {
+	delay := 100 * time.Millisecond
+	for i := 0; i < attempt; i++ {
+		delay *= 4
+	}
+	return delay
+}
+
+// doWithRetry executes an HTTP request built by buildRequest, retrying
+// idempotent requests that fail with a transient network error or a
+// 502/503/504 status up to maxRetries times (0 means the request is tried
+// once and never retried; callers that want a configurable default should
+// resolve that before calling in, e.g. via PluginSettings.MaxRetriesOrDefault).
+// buildRequest is called once per attempt so the request (and its body
+// reader) is rebuilt from scratch every time. Retries back off
+// exponentially and stop early once the context deadline would be exceeded.
+func doWithRetry(ctx context.Context, client *http.Client, maxRetries int, buildRequest func() (*http.Request, error)) (*http.Response, error) {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	maxAttempts := maxRetries + 1
+
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := retryBackoff(attempt - 1)
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		request, err := buildRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(request)
+		if err != nil {
+			lastErr = err
+			if attempt < maxAttempts-1 && isTransientError(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		if attempt < maxAttempts-1 && isTransientStatus(resp.StatusCode) {
+			lastErr = fmt.Errorf("transient status %d", resp.StatusCode)
+			resp.Body.Close()
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// isTransientError reports whether err looks like a temporary network
+// failure worth retrying, rather than a permanent one.
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return true
+		}
+		if temp, ok := interface{}(netErr).(interface{ Temporary() bool }); ok && temp.Temporary() {
+			return true
+		}
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Err != nil {
+		if errors.Is(urlErr.Err, io.EOF) || errors.Is(urlErr.Err, syscall.ECONNRESET) {
+			return true
+		}
+		msg := urlErr.Err.Error()
+		if strings.Contains(msg, "connection reset by peer") || strings.Contains(msg, "broken pipe") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTransientStatus reports whether the HTTP status code indicates a
+// temporary upstream failure worth retrying.
+func isTransientStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
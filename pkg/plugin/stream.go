@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// liveQuerySpec is the query a live stream channel was created for. QueryData
+// records one of these when it hands back a channel URI, and RunStream looks
+// it up to know how to decode and coerce the events it receives.
+type liveQuerySpec struct {
+	Device         string
+	XPathQuery     string
+	Type           string
+	ContainsString string
+	LabelXpath     string
+}
+
+// liveChannel builds the stream channel path for a live query: ds/{device}/{query-hash}.
+// The hash covers every field that affects how events on the channel get
+// decoded (xpath, type, containsString, labelXpath), not just the xpath, so
+// two queries against the same device/xpath that differ only in type or
+// label don't collide on the same channel.
+func liveChannel(spec liveQuerySpec) string {
+	return fmt.Sprintf("ds/%s/%s", spec.Device, queryHash(spec))
+}
+
+// queryHash hashes the parts of a liveQuerySpec that affect decoding down
+// to a short hex string suitable for use as a channel path segment.
+func queryHash(spec liveQuerySpec) string {
+	h := fnv.New64a()
+	for _, part := range []string{spec.XPathQuery, spec.Type, spec.ContainsString, spec.LabelXpath} {
+		_, _ = h.Write([]byte(part))
+		_, _ = h.Write([]byte{0})
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// SubscribeStream validates that path is a channel QueryData has handed out
+// for a live query.
+func (d *Datasource) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	parts := strings.Split(req.Path, "/")
+	if len(parts) != 3 || parts[0] != "ds" || parts[1] == "" || parts[2] == "" {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+
+	d.liveQueriesMu.RLock()
+	_, ok := d.liveQueries[req.Path]
+	d.liveQueriesMu.RUnlock()
+	if !ok {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream rejects all client writes; this datasource only ever
+// produces stream data, it never consumes it.
+func (d *Datasource) PublishStream(_ context.Context, _ *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream opens a persistent connection to the aggregator's event stream
+// for req.Path's device/xpath and forwards each decoded event to sender as a
+// one-row frame, reconnecting with backoff if the connection drops.
+func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	d.liveQueriesMu.RLock()
+	spec, ok := d.liveQueries[req.Path]
+	d.liveQueriesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown stream channel: %s", req.Path)
+	}
+
+	attempt := 0
+	for {
+		err := d.runStreamOnce(ctx, spec, sender)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		backend.Logger.Warn("netconf event stream disconnected, reconnecting", "channel", req.Path, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryBackoff(attempt)):
+		}
+		if attempt < 5 {
+			attempt++
+		}
+	}
+}
+
+// runStreamOnce opens one SSE connection to the aggregator and forwards
+// events until the connection ends or ctx is cancelled.
+func (d *Datasource) runStreamOnce(ctx context.Context, spec liveQuerySpec, sender *backend.StreamSender) error {
+	if d.config.Address == "" {
+		return fmt.Errorf("datasource address is not configured")
+	}
+
+	subscribeURL := fmt.Sprintf("%s/subscribe/%s", d.config.Address, spec.Device)
+	bodyBytes, err := json.Marshal(map[string]string{"xpath": spec.XPathQuery, "type": spec.Type})
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscribe request: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", subscribeURL, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create subscribe request: %w", err)
+	}
+	request.Header.Set("Accept", "text/event-stream")
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.streamClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to open event stream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("aggregator returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		payload, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		payload = strings.TrimSpace(payload)
+		if payload == "" {
+			continue
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			backend.Logger.Warn("failed to decode netconf stream event", "error", err)
+			continue
+		}
+
+		processed, err := processSnapshots([]map[string]interface{}{event}, spec.XPathQuery, spec.Type, spec.ContainsString, spec.LabelXpath)
+		if err != nil {
+			backend.Logger.Warn("failed to process netconf stream event", "error", err)
+			continue
+		}
+
+		frame, err := buildFrame(spec.Type, processed)
+		if err != nil {
+			backend.Logger.Warn("failed to build netconf stream frame", "error", err)
+			continue
+		}
+
+		if err := sender.SendFrame(frame, data.IncludeAll); err != nil {
+			return fmt.Errorf("failed to send frame: %w", err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("event stream closed: %w", err)
+	}
+
+	return fmt.Errorf("event stream ended")
+}
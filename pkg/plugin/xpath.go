@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+// TypedValue is a single value extracted from a device XML snapshot, coerced
+// to the Grafana field type requested by the query. Label is only populated
+// for "multi" queries, where it comes from an optional labelXpath.
+type TypedValue struct {
+	Value interface{}
+	Label string
+}
+
+// evaluateXPath parses xmlData and evaluates xpathQuery against it, coercing
+// the matched node(s) into the type requested by qtype.
+//
+// For "multi" it returns one TypedValue per matched node, labelled via
+// labelXpath (falling back to the node's own text when labelXpath is empty
+// or matches nothing under that node).
+// Every other type returns at most one TypedValue, taken from the first
+// matched node. "contains" is handled separately since it never needs an
+// xpath match, only a substring check against the raw XML.
+func evaluateXPath(xmlData string, xpathQuery string, labelXpath string, qtype string, qstring string) ([]TypedValue, error) {
+	if qtype == "contains" {
+		return []TypedValue{{Value: strings.Contains(xmlData, qstring)}}, nil
+	}
+
+	doc, err := xmlquery.Parse(strings.NewReader(xmlData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	}
+
+	nodes, err := xmlquery.QueryAll(doc, xpathQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %q: %w", xpathQuery, err)
+	}
+
+	if qtype == "multi" {
+		values := make([]TypedValue, 0, len(nodes))
+		for _, n := range nodes {
+			label := strings.TrimSpace(n.InnerText())
+			if labelXpath != "" {
+				if labelNode := xmlquery.FindOne(n, labelXpath); labelNode != nil {
+					label = strings.TrimSpace(labelNode.InnerText())
+				}
+			}
+			values = append(values, TypedValue{Value: strings.TrimSpace(n.InnerText()), Label: label})
+		}
+		return values, nil
+	}
+
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	value, err := coerce(strings.TrimSpace(nodes[0].InnerText()), qtype)
+	if err != nil {
+		return nil, err
+	}
+	return []TypedValue{{Value: value}}, nil
+}
+
+// coerce converts the raw text of a matched XPath node into the Go type
+// backing the requested query type.
+func coerce(text string, qtype string) (interface{}, error) {
+	switch qtype {
+	case "int":
+		v, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not an integer: %w", text, err)
+		}
+		return v, nil
+	case "float":
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not a float: %w", text, err)
+		}
+		return v, nil
+	case "string", "enum":
+		return text, nil
+	default:
+		return nil, fmt.Errorf("unsupported query type: %s", qtype)
+	}
+}
@@ -0,0 +1,102 @@
+package plugin
+
+import "testing"
+
+func TestEvaluateXPath_IntParsesNegativeNumbers(t *testing.T) {
+	values, err := evaluateXPath(`<root><v>-42</v></root>`, "//v", "", "int", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 1 || values[0].Value != int64(-42) {
+		t.Fatalf("got %+v, want a single -42", values)
+	}
+}
+
+func TestEvaluateXPath_FloatParsesDecimals(t *testing.T) {
+	values, err := evaluateXPath(`<root><v>-3.14</v></root>`, "//v", "", "float", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 1 || values[0].Value != -3.14 {
+		t.Fatalf("got %+v, want a single -3.14", values)
+	}
+}
+
+func TestEvaluateXPath_MultiWithLabelXpath(t *testing.T) {
+	xml := `<root>
+		<item><name>eth0</name><rate>100</rate></item>
+		<item><name>eth1</name><rate>200</rate></item>
+	</root>`
+
+	values, err := evaluateXPath(xml, "//item/rate", "../name", "multi", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("got %d values, want 2", len(values))
+	}
+	if values[0].Value != "100" || values[0].Label != "eth0" {
+		t.Fatalf("got %+v, want value 100 labelled eth0", values[0])
+	}
+	if values[1].Value != "200" || values[1].Label != "eth1" {
+		t.Fatalf("got %+v, want value 200 labelled eth1", values[1])
+	}
+}
+
+func TestEvaluateXPath_MultiFallsBackToNodeTextWithoutLabelXpath(t *testing.T) {
+	xml := `<root><item>hello</item></root>`
+
+	values, err := evaluateXPath(xml, "//item", "", "multi", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 1 {
+		t.Fatalf("got %d values, want 1", len(values))
+	}
+	if values[0].Value != "hello" || values[0].Label != "hello" {
+		t.Fatalf("got %+v, want value and label both hello", values[0])
+	}
+}
+
+func TestEvaluateXPath_ZeroMatches(t *testing.T) {
+	xml := `<root></root>`
+
+	cases := []string{"int", "float", "string", "enum"}
+	for _, qtype := range cases {
+		t.Run(qtype, func(t *testing.T) {
+			values, err := evaluateXPath(xml, "//missing", "", qtype, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if values != nil {
+				t.Fatalf("got %+v, want no values", values)
+			}
+		})
+	}
+
+	t.Run("multi", func(t *testing.T) {
+		values, err := evaluateXPath(xml, "//missing", "", "multi", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(values) != 0 {
+			t.Fatalf("got %+v, want no values", values)
+		}
+	})
+}
+
+func TestEvaluateXPath_ContainsBypassesXPath(t *testing.T) {
+	values, err := evaluateXPath(`not even xml`, "//ignored", "", "contains", "even")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 1 || values[0].Value != true {
+		t.Fatalf("got %+v, want a single true", values)
+	}
+}
+
+func TestCoerce_IntRejectsNonNumeric(t *testing.T) {
+	if _, err := coerce("not-a-number", "int"); err == nil {
+		t.Fatal("expected an error for a non-numeric int value")
+	}
+}